@@ -0,0 +1,252 @@
+// Copyright 2018 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	ptypes "github.com/pingcap/parser/types"
+	tidbtypes "github.com/pingcap/tidb/types"
+	tidbjson "github.com/pingcap/tidb/types/json"
+)
+
+// ReplacePlaceholder replaces the '?' placeholders in str, in order, with
+// the quoted values in args. It's used for pretty-printing a prepared
+// statement, not for building SQL to execute.
+func ReplacePlaceholder(str string, args []string) string {
+	newArgs := make([]interface{}, 0, len(args))
+	for _, arg := range args {
+		newArgs = append(newArgs, fmt.Sprintf("'%s'", arg))
+	}
+	return fmt.Sprintf(strings.Replace(str, "?", "%s", -1), newArgs...)
+}
+
+// TableName returns the precise full name of a table in MySQL, e.g
+// "`test`.`t1`". It's a thin wrapper over TableNameWithQuoter(MySQLQuoter, ...);
+// use that directly to emit SQL for a different dialect.
+func TableName(schema, table string) string {
+	return TableNameWithQuoter(MySQLQuoter, schema, table)
+}
+
+// ColumnName returns the precise full name of a column in MySQL, e.g
+// "`id`". It's a thin wrapper over ColumnNameWithQuoter(MySQLQuoter, ...);
+// use that directly to emit SQL for a different dialect.
+func ColumnName(name string) string {
+	return ColumnNameWithQuoter(MySQLQuoter, name)
+}
+
+// parseSQLMode parses sqlModeStr (a comma separated SQL mode list, as
+// reported by `SELECT @@sql_mode`) into a mysql.SQLMode bitmask.
+func parseSQLMode(sqlModeStr string) (mysql.SQLMode, error) {
+	sqlMode, err := mysql.GetSQLMode(sqlModeStr)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	return sqlMode, nil
+}
+
+// getParser returns a parser configured with the SQL mode described by
+// sqlModeStr.
+func getParser(sqlModeStr string) (*parser.Parser, error) {
+	sqlMode, err := parseSQLMode(sqlModeStr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser.New()
+	p.SetSQLMode(sqlMode)
+	return p, nil
+}
+
+// FormatTimeZoneOffset formats a time.Duration UTC offset as a MySQL time
+// zone string, e.g. "+08:00" or "-01:30".
+func FormatTimeZoneOffset(offset time.Duration) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+
+	hour := offset / time.Hour
+	offset -= hour * time.Hour
+	minute := offset / time.Minute
+
+	return fmt.Sprintf("%s%02d:%02d", sign, hour, minute)
+}
+
+// AnalyzeValuesFromBuckets analyzes the bucket boundary value reported by
+// `SHOW STATS_BUCKETS`/`SHOW STATS_HISTOGRAMS` back into human-readable
+// text, one value per column in cols.
+//
+// For most types the stored value is already human-readable, but TiDB packs
+// some types (the time family, YEAR, DECIMAL, ENUM, SET and JSON) into their
+// internal representation, so those need decoding first.
+func AnalyzeValuesFromBuckets(value string, cols []*model.ColumnInfo) ([]string, error) {
+	if len(cols) == 0 {
+		return nil, nil
+	}
+
+	valueStrs := strings.Split(value, ",")
+	if len(valueStrs) != len(cols) {
+		// the value isn't a comma joined tuple, so assume it's the encoding
+		// of a single column.
+		if len(cols) != 1 {
+			return nil, errors.Errorf("value %s doesn't match the %d columns", value, len(cols))
+		}
+		valueStrs = []string{value}
+	}
+
+	values := make([]string, 0, len(cols))
+	for i, col := range cols {
+		valueStr, err := decodeBucketValue(valueStrs[i], col)
+		if err != nil {
+			return nil, errors.Annotatef(err, "decode value %s for column %s", valueStrs[i], col.Name)
+		}
+		values = append(values, valueStr)
+	}
+
+	return values, nil
+}
+
+// decodeBucketValue decodes a single bucket boundary value according to
+// col's type.
+func decodeBucketValue(value string, col *model.ColumnInfo) (string, error) {
+	switch col.FieldType.Tp {
+	case mysql.TypeDate, mysql.TypeDatetime, mysql.TypeTimestamp:
+		return decodeBucketDatetime(value)
+	case mysql.TypeDuration:
+		return decodeBucketDuration(value, &col.FieldType)
+	case mysql.TypeYear:
+		return decodeBucketYear(value)
+	case mysql.TypeNewDecimal:
+		return decodeBucketDecimal(value)
+	case mysql.TypeEnum:
+		return decodeBucketEnum(value, &col.FieldType)
+	case mysql.TypeSet:
+		return decodeBucketSet(value, &col.FieldType)
+	case mysql.TypeJSON:
+		return decodeBucketJSON(value)
+	case mysql.TypeGeometry, mysql.TypeBit:
+		// these types have no readable-text bucket encoding to fall back
+		// to, unlike the ordinary types handled by default below.
+		return "", errors.Errorf("unsupported column type %v for bucket value decoding", col.FieldType.Tp)
+	default:
+		// INT, BIGINT, VARCHAR, CHAR, FLOAT, DOUBLE, TEXT, etc. already
+		// store a human-readable bucket value, so pass it through as-is.
+		return value, nil
+	}
+}
+
+// decodeBucketDatetime decodes the packed uint64 TiDB uses to store
+// TypeDate/TypeDatetime/TypeTimestamp values in stats buckets. Values that
+// are already textual (e.g. produced by an older TiDB) are passed through
+// unchanged.
+func decodeBucketDatetime(value string) (string, error) {
+	numVal, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return value, nil
+	}
+
+	t := tidbtypes.NewTime(tidbtypes.FromPackedUint(numVal), mysql.TypeDatetime, tidbtypes.DefaultFsp)
+	return t.String(), nil
+}
+
+// decodeBucketDuration decodes the packed int64 (nanoseconds) TiDB uses to
+// store TypeDuration values in stats buckets, the same encoding
+// builtinCastDecimalAsDuration produces.
+func decodeBucketDuration(value string, ft *ptypes.FieldType) (string, error) {
+	numVal, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return value, nil
+	}
+
+	fsp := ft.Decimal
+	if fsp < 0 {
+		fsp = tidbtypes.DefaultFsp
+	}
+	dur := tidbtypes.Duration{Duration: time.Duration(numVal), Fsp: fsp}
+	return dur.String(), nil
+}
+
+// decodeBucketYear normalizes the 2-digit year form TiDB sometimes stores in
+// buckets to the canonical 4-digit year.
+func decodeBucketYear(value string) (string, error) {
+	yearVal, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return value, nil
+	}
+
+	year, err := tidbtypes.AdjustYear(yearVal)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return strconv.FormatInt(year, 10), nil
+}
+
+// decodeBucketDecimal decodes the bucket value of a TypeNewDecimal column
+// into its canonical decimal text form.
+func decodeBucketDecimal(value string) (string, error) {
+	dec := new(tidbtypes.MyDecimal)
+	if err := dec.FromString([]byte(value)); err != nil {
+		return "", errors.Trace(err)
+	}
+	return dec.String(), nil
+}
+
+// decodeBucketEnum resolves the integer index stored for a TypeEnum bucket
+// value against the column's declared Elems.
+func decodeBucketEnum(value string, ft *ptypes.FieldType) (string, error) {
+	idx, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	e, err := tidbtypes.ParseEnumValue(ft.Elems, idx)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return e.Name, nil
+}
+
+// decodeBucketSet resolves the integer bitmask stored for a TypeSet bucket
+// value against the column's declared Elems.
+func decodeBucketSet(value string, ft *ptypes.FieldType) (string, error) {
+	bits, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	s, err := tidbtypes.ParseSetValue(ft.Elems, bits)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return s.Name, nil
+}
+
+// decodeBucketJSON re-emits the binary JSON representation stored in a
+// TypeJSON bucket value as canonical JSON text.
+func decodeBucketJSON(value string) (string, error) {
+	if len(value) == 0 {
+		return "", errors.New("empty JSON bucket value")
+	}
+	bj := tidbjson.BinaryJSON{TypeCode: value[0], Value: []byte(value[1:])}
+	return bj.String(), nil
+}
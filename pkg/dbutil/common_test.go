@@ -14,10 +14,8 @@
 package dbutil
 
 import (
-	"context"
 	"time"
 
-	sqlmock "github.com/DATA-DOG/go-sqlmock"
 	"github.com/go-sql-driver/mysql"
 	. "github.com/pingcap/check"
 	"github.com/pingcap/errors"
@@ -25,6 +23,7 @@ import (
 	pmysql "github.com/pingcap/parser/mysql"
 	"github.com/pingcap/parser/types"
 	"github.com/pingcap/tidb/infoschema"
+	tidbjson "github.com/pingcap/tidb/types/json"
 )
 
 func (*testDBSuite) TestReplacePlaceholder(c *C) {
@@ -134,22 +133,6 @@ func (s *testDBSuite) TestIsIgnoreError(c *C) {
 	}
 }
 
-func (s *testDBSuite) TestDeleteRows(c *C) {
-	db, mock, err := sqlmock.New()
-	c.Assert(err, IsNil)
-
-	// delete twice
-	mock.ExpectExec("DELETE FROM").WillReturnResult(sqlmock.NewResult(0, DefaultDeleteRowsNum))
-	mock.ExpectExec("DELETE FROM").WillReturnResult(sqlmock.NewResult(0, DefaultDeleteRowsNum-1))
-
-	err = DeleteRows(context.Background(), db, "test", "t", "", nil)
-	c.Assert(err, IsNil)
-
-	if err := mock.ExpectationsWereMet(); err != nil {
-		c.Errorf("there were unfulfilled expectations: %s", err)
-	}
-}
-
 func (s *testDBSuite) TestGetParser(c *C) {
 	testCases := []struct {
 		sqlModeStr string
@@ -220,6 +203,46 @@ func (s *testDBSuite) TestAnalyzeValuesFromBuckets(c *C) {
 			&model.ColumnInfo{FieldType: types.FieldType{Tp: pmysql.TypeDate}},
 			"2020-01-01 00:00:00",
 		},
+		{
+			"36000000000000",
+			&model.ColumnInfo{FieldType: types.FieldType{Tp: pmysql.TypeDuration, Decimal: 0}},
+			"10:00:00",
+		},
+		{
+			"21",
+			&model.ColumnInfo{FieldType: types.FieldType{Tp: pmysql.TypeYear}},
+			"2021",
+		},
+		{
+			"123.450",
+			&model.ColumnInfo{FieldType: types.FieldType{Tp: pmysql.TypeNewDecimal}},
+			"123.450",
+		},
+		{
+			"2",
+			&model.ColumnInfo{FieldType: types.FieldType{Tp: pmysql.TypeEnum, Elems: []string{"a", "b", "c"}}},
+			"b",
+		},
+		{
+			"3",
+			&model.ColumnInfo{FieldType: types.FieldType{Tp: pmysql.TypeSet, Elems: []string{"a", "b", "c"}}},
+			"a,b",
+		},
+		{
+			jsonBucketValue(c, map[string]interface{}{"a": int64(1)}),
+			&model.ColumnInfo{FieldType: types.FieldType{Tp: pmysql.TypeJSON}},
+			`{"a": 1}`,
+		},
+		{
+			"123",
+			&model.ColumnInfo{FieldType: types.FieldType{Tp: pmysql.TypeLong}},
+			"123",
+		},
+		{
+			"hello",
+			&model.ColumnInfo{FieldType: types.FieldType{Tp: pmysql.TypeVarchar}},
+			"hello",
+		},
 	}
 	for _, ca := range cases {
 		val, err := AnalyzeValuesFromBuckets(ca.value, []*model.ColumnInfo{ca.col})
@@ -229,6 +252,19 @@ func (s *testDBSuite) TestAnalyzeValuesFromBuckets(c *C) {
 	}
 }
 
+// jsonBucketValue encodes goVal the same way TiDB stores a JSON column's
+// value in a stats bucket, for use as test input to AnalyzeValuesFromBuckets.
+func jsonBucketValue(c *C, goVal interface{}) string {
+	bj := tidbjson.CreateBinary(goVal)
+	return string(append([]byte{bj.TypeCode}, bj.Value...))
+}
+
+func (s *testDBSuite) TestAnalyzeValuesFromBucketsUnsupportedType(c *C) {
+	col := &model.ColumnInfo{FieldType: types.FieldType{Tp: pmysql.TypeGeometry}}
+	_, err := AnalyzeValuesFromBuckets("some value", []*model.ColumnInfo{col})
+	c.Assert(err, NotNil)
+}
+
 func (s *testDBSuite) TestFormatTimeZoneOffset(c *C) {
 	cases := map[string]time.Duration{
 		"+00:00": 0,
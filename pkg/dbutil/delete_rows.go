@@ -0,0 +1,352 @@
+// Copyright 2018 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/pingcap/errors"
+)
+
+// DefaultDeleteRowsNum is the default number of rows a single DELETE
+// statement issued by DeleteRows targets.
+const DefaultDeleteRowsNum = 10000
+
+const (
+	defaultMinBatchSize       = 100
+	defaultMaxBatchSize       = 100000
+	defaultTargetBatchLatency = 500 * time.Millisecond
+)
+
+// ProgressFunc is called after every batch DeleteRows deletes, reporting how
+// many rows have been deleted so far and, if totalEstimate is known, an
+// estimate of the total number of rows being deleted. totalEstimate is -1
+// when unknown.
+type ProgressFunc func(deleted, totalEstimate int64)
+
+// PKRange bounds a DELETE to rows whose Column falls within [Start, End),
+// letting the server prune the scan instead of reading the whole table.
+// Start and/or End may be empty to leave that side unbounded.
+type PKRange struct {
+	Column string
+	Start  string
+	End    string
+}
+
+// DeleteOptions configures the adaptive batching DeleteRows performs when
+// it's given at least one Option; see DeleteRows.
+type DeleteOptions struct {
+	// Policy classifies the errors DeleteRows encounters. Defaults to the
+	// package's default ErrorPolicy.
+	Policy *ErrorPolicy
+
+	// InitialBatchSize is the row count the first DELETE statement
+	// targets. Defaults to DefaultDeleteRowsNum.
+	InitialBatchSize int64
+	// MinBatchSize and MaxBatchSize bound how far the adaptive batcher
+	// will shrink or grow InitialBatchSize. Default to 100 and 100000.
+	MinBatchSize int64
+	MaxBatchSize int64
+	// TargetBatchLatency is the latency the batcher tries to keep each
+	// DELETE statement under: it grows the batch size additively while
+	// under target, and shrinks it multiplicatively once over. Defaults
+	// to 500ms.
+	TargetBatchLatency time.Duration
+
+	// QPSLimit caps the number of DELETE statements issued per second.
+	// Zero (the default) means unlimited.
+	QPSLimit float64
+
+	// Progress, if set, is called after every batch with the cumulative
+	// number of rows deleted so far.
+	Progress ProgressFunc
+
+	// PKRanges, if set, splits the delete into statements bounded to each
+	// range in turn, so the server can prune instead of scanning the
+	// whole table. Used for tables with a known primary-key range or
+	// that are partitioned.
+	PKRanges []PKRange
+}
+
+// Option configures a DeleteOptions. Passing any Option to DeleteRows is
+// what switches it from its original, fixed-batch-size behaviour to the
+// adaptive batcher built on top of the resulting DeleteOptions.
+type Option func(*DeleteOptions)
+
+// WithPolicy overrides the ErrorPolicy DeleteRows uses to classify the
+// errors it encounters, instead of the package default.
+func WithPolicy(policy *ErrorPolicy) Option {
+	return func(o *DeleteOptions) { o.Policy = policy }
+}
+
+// WithInitialBatchSize sets the row count the first DELETE statement
+// targets. Defaults to DefaultDeleteRowsNum.
+func WithInitialBatchSize(n int64) Option {
+	return func(o *DeleteOptions) { o.InitialBatchSize = n }
+}
+
+// WithBatchSizeRange bounds how far the adaptive batcher will shrink or
+// grow the batch size. Defaults to 100 and 100000.
+func WithBatchSizeRange(min, max int64) Option {
+	return func(o *DeleteOptions) {
+		o.MinBatchSize = min
+		o.MaxBatchSize = max
+	}
+}
+
+// WithTargetBatchLatency sets the latency the batcher tries to keep each
+// DELETE statement under, growing the batch size additively while under it
+// and shrinking it multiplicatively once over. Defaults to 500ms.
+func WithTargetBatchLatency(d time.Duration) Option {
+	return func(o *DeleteOptions) { o.TargetBatchLatency = d }
+}
+
+// WithQPSLimit caps the number of DELETE statements issued per second.
+func WithQPSLimit(qps float64) Option {
+	return func(o *DeleteOptions) { o.QPSLimit = qps }
+}
+
+// WithProgress registers a callback invoked after every batch with the
+// cumulative number of rows deleted so far.
+func WithProgress(fn ProgressFunc) Option {
+	return func(o *DeleteOptions) { o.Progress = fn }
+}
+
+// WithPKRanges splits the delete into statements bounded to each range,
+// working through the ranges in order, so the server can prune instead of
+// scanning the whole table. Used
+// for tables with a known primary-key range or that are partitioned.
+func WithPKRanges(ranges ...PKRange) Option {
+	return func(o *DeleteOptions) { o.PKRanges = ranges }
+}
+
+func newDeleteOptions(opts ...Option) *DeleteOptions {
+	o := &DeleteOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.Policy == nil {
+		o.Policy = defaultErrorPolicy
+	}
+	if o.InitialBatchSize == 0 {
+		o.InitialBatchSize = DefaultDeleteRowsNum
+	}
+	if o.MinBatchSize == 0 {
+		o.MinBatchSize = defaultMinBatchSize
+	}
+	if o.MaxBatchSize == 0 {
+		o.MaxBatchSize = defaultMaxBatchSize
+	}
+	if o.TargetBatchLatency == 0 {
+		o.TargetBatchLatency = defaultTargetBatchLatency
+	}
+	if len(o.PKRanges) == 0 {
+		o.PKRanges = []PKRange{{}}
+	}
+	return o
+}
+
+// qpsLimiter is a simple token-bucket rate limiter: one token is refilled
+// every 1/qps, up to a single token of burst. A zero-value qpsLimiter (or
+// one with qps <= 0) never blocks.
+type qpsLimiter struct {
+	interval time.Duration
+	last     time.Time
+}
+
+func newQPSLimiter(qps float64) *qpsLimiter {
+	if qps <= 0 {
+		return &qpsLimiter{}
+	}
+	return &qpsLimiter{interval: time.Duration(float64(time.Second) / qps)}
+}
+
+// wait blocks until either ctx is done or enough time has passed since the
+// previous call to respect the configured QPS.
+func (l *qpsLimiter) wait(ctx context.Context) error {
+	if l.interval == 0 {
+		return nil
+	}
+
+	if !l.last.IsZero() {
+		if sleep := l.interval - time.Since(l.last); sleep > 0 {
+			select {
+			case <-time.After(sleep):
+			case <-ctx.Done():
+				return errors.Trace(ctx.Err())
+			}
+		}
+	}
+	l.last = time.Now()
+	return nil
+}
+
+// DeleteRows deletes rows from schemaName.tableName that satisfy where.
+//
+// Without any Option, it reproduces exactly what it always has: fixed
+// DefaultDeleteRowsNum-sized DELETE statements, retried against the
+// package's default ErrorPolicy, until a batch affects fewer rows than
+// that. Passing WithPolicy, WithInitialBatchSize, etc. switches it to an
+// adaptive batcher built on the resulting DeleteOptions, which keeps
+// per-statement latency under a target
+// (additive-increase/multiplicative-decrease), throttles itself to a QPS
+// limit, reports progress, honors ctx cancellation between batches, and —
+// with WithPKRanges — bounds each batch's DELETE to one range at a time so
+// the server can prune.
+func DeleteRows(ctx context.Context, db *sql.DB, schemaName, tableName, where string, args []interface{}, opts ...Option) error {
+	if len(opts) == 0 {
+		return deleteRowsFixedBatch(ctx, db, schemaName, tableName, where, args)
+	}
+
+	o := newDeleteOptions(opts...)
+	limiter := newQPSLimiter(o.QPSLimit)
+	batchSize := o.InitialBatchSize
+	var deleted int64
+
+	for _, pkRange := range o.PKRanges {
+		for {
+			query, queryArgs := buildDeleteQuery(schemaName, tableName, where, args, pkRange, batchSize)
+
+			if err := limiter.wait(ctx); err != nil {
+				return err
+			}
+
+			rows, latency, err := execDeleteBatch(ctx, db, query, queryArgs, o.Policy)
+			if err != nil {
+				return err
+			}
+
+			deleted += rows
+			if o.Progress != nil {
+				o.Progress(deleted, -1)
+			}
+
+			requestedBatchSize := batchSize
+			batchSize = adjustBatchSize(batchSize, latency, o)
+
+			if rows < requestedBatchSize {
+				break
+			}
+
+			select {
+			case <-ctx.Done():
+				return errors.Trace(ctx.Err())
+			default:
+			}
+		}
+	}
+
+	return nil
+}
+
+// deleteRowsFixedBatch is DeleteRows' original behaviour: fixed
+// DefaultDeleteRowsNum-sized batches against the package's default
+// ErrorPolicy, no adaptive resizing and no QPS limiting.
+func deleteRowsFixedBatch(ctx context.Context, db *sql.DB, schemaName, tableName, where string, args []interface{}) error {
+	query, queryArgs := buildDeleteQuery(schemaName, tableName, where, args, PKRange{}, DefaultDeleteRowsNum)
+
+	for {
+		rows, _, err := execDeleteBatch(ctx, db, query, queryArgs, defaultErrorPolicy)
+		if err != nil {
+			return err
+		}
+
+		if rows < DefaultDeleteRowsNum {
+			break
+		}
+	}
+
+	return nil
+}
+
+func buildDeleteQuery(schemaName, tableName, where string, args []interface{}, pkRange PKRange, limit int64) (string, []interface{}) {
+	query := fmt.Sprintf("DELETE FROM %s", TableName(schemaName, tableName))
+
+	conditions := make([]string, 0, 2)
+	queryArgs := make([]interface{}, 0, len(args)+2)
+	if len(where) != 0 {
+		conditions = append(conditions, fmt.Sprintf("(%s)", where))
+		queryArgs = append(queryArgs, args...)
+	}
+	if pkRange.Column != "" {
+		if pkRange.Start != "" {
+			conditions = append(conditions, fmt.Sprintf("%s >= ?", ColumnName(pkRange.Column)))
+			queryArgs = append(queryArgs, pkRange.Start)
+		}
+		if pkRange.End != "" {
+			conditions = append(conditions, fmt.Sprintf("%s < ?", ColumnName(pkRange.Column)))
+			queryArgs = append(queryArgs, pkRange.End)
+		}
+	}
+	if len(conditions) != 0 {
+		query += " WHERE " + conditions[0]
+		for _, cond := range conditions[1:] {
+			query += " AND " + cond
+		}
+	}
+	query += fmt.Sprintf(" LIMIT %d", limit)
+
+	return query, queryArgs
+}
+
+// execDeleteBatch runs query once, retrying according to policy when the
+// error is classified as retryable, and returns the number of rows
+// affected together with how long the (eventually) successful attempt took.
+func execDeleteBatch(ctx context.Context, db *sql.DB, query string, args []interface{}, policy *ErrorPolicy) (int64, time.Duration, error) {
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		result, err := db.ExecContext(ctx, query, args...)
+		latency := time.Since(start)
+		if err == nil {
+			rows, err := result.RowsAffected()
+			if err != nil {
+				return 0, latency, errors.Trace(err)
+			}
+			return rows, latency, nil
+		}
+
+		retry, backoff := policy.ShouldRetry(err, attempt)
+		if !retry {
+			return 0, latency, errors.Trace(err)
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return 0, latency, errors.Trace(ctx.Err())
+		}
+	}
+}
+
+// adjustBatchSize grows batchSize additively while latency stays under
+// target, and shrinks it multiplicatively (halving) once it exceeds target,
+// clamped to [opts.MinBatchSize, opts.MaxBatchSize].
+func adjustBatchSize(batchSize int64, latency time.Duration, opts *DeleteOptions) int64 {
+	next := batchSize
+	if latency > opts.TargetBatchLatency {
+		next = batchSize / 2
+	} else {
+		next = batchSize + opts.MinBatchSize
+	}
+
+	if next < opts.MinBatchSize {
+		next = opts.MinBatchSize
+	}
+	if next > opts.MaxBatchSize {
+		next = opts.MaxBatchSize
+	}
+	return next
+}
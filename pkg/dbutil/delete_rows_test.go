@@ -0,0 +1,120 @@
+// Copyright 2018 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbutil
+
+import (
+	"context"
+	"fmt"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	. "github.com/pingcap/check"
+)
+
+func (s *testDBSuite) TestDeleteRows(c *C) {
+	db, mock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+
+	// delete twice, each batch staying at the fixed DefaultDeleteRowsNum
+	// size: with no Option passed, DeleteRows must never adapt its batch
+	// size, only the original ...Num, ...Num-1 loop-termination behaviour.
+	limitClause := fmt.Sprintf("LIMIT %d$", DefaultDeleteRowsNum)
+	mock.ExpectExec(limitClause).WillReturnResult(sqlmock.NewResult(0, DefaultDeleteRowsNum))
+	mock.ExpectExec(limitClause).WillReturnResult(sqlmock.NewResult(0, DefaultDeleteRowsNum-1))
+
+	err = DeleteRows(context.Background(), db, "test", "t", "", nil)
+	c.Assert(err, IsNil)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		c.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (s *testDBSuite) TestDeleteRowsProgress(c *C) {
+	db, mock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+
+	mock.ExpectExec("DELETE FROM").WillReturnResult(sqlmock.NewResult(0, 50))
+	mock.ExpectExec("DELETE FROM").WillReturnResult(sqlmock.NewResult(0, 10))
+
+	var deletedSeen []int64
+	err = DeleteRows(context.Background(), db, "test", "t", "", nil,
+		WithInitialBatchSize(50),
+		WithProgress(func(deleted, totalEstimate int64) {
+			deletedSeen = append(deletedSeen, deleted)
+		}),
+	)
+	c.Assert(err, IsNil)
+	c.Assert(deletedSeen, DeepEquals, []int64{50, 60})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		c.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (s *testDBSuite) TestDeleteRowsCancellation(c *C) {
+	db, mock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+
+	// first batch fills the requested size, so DeleteRows would normally
+	// keep going; cancelling ctx from the progress callback must stop it
+	// before a second batch is issued.
+	mock.ExpectExec("DELETE FROM").WillReturnResult(sqlmock.NewResult(0, DefaultDeleteRowsNum))
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	err = DeleteRows(ctx, db, "test", "t", "", nil,
+		WithInitialBatchSize(DefaultDeleteRowsNum),
+		WithProgress(func(deleted, totalEstimate int64) {
+			cancel()
+		}),
+	)
+	c.Assert(err, NotNil)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		c.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (s *testDBSuite) TestDeleteRowsPKRanges(c *C) {
+	db, mock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+
+	mock.ExpectExec("DELETE FROM").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = DeleteRows(context.Background(), db, "test", "t", "", nil,
+		WithInitialBatchSize(100),
+		WithPKRanges(
+			PKRange{Column: "id", Start: "0", End: "100"},
+			PKRange{Column: "id", Start: "100", End: "200"},
+		),
+	)
+	c.Assert(err, IsNil)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		c.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func (s *testDBSuite) TestAdjustBatchSize(c *C) {
+	opts := newDeleteOptions(WithBatchSizeRange(100, 1000), WithTargetBatchLatency(defaultTargetBatchLatency))
+
+	// under target latency: grows additively
+	c.Assert(adjustBatchSize(500, 0, opts), Equals, int64(600))
+	// over target latency: shrinks multiplicatively
+	c.Assert(adjustBatchSize(500, defaultTargetBatchLatency*2, opts), Equals, int64(250))
+	// clamped to MinBatchSize/MaxBatchSize
+	c.Assert(adjustBatchSize(100, defaultTargetBatchLatency*2, opts), Equals, int64(100))
+	c.Assert(adjustBatchSize(950, 0, opts), Equals, int64(1000))
+}
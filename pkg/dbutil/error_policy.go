@@ -0,0 +1,162 @@
+// Copyright 2018 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbutil
+
+import (
+	"time"
+
+	gmysql "github.com/go-sql-driver/mysql"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/infoschema"
+)
+
+// Category classifies an error returned by a MySQL/TiDB server so that
+// callers (sync-diff-inspector, dumpling, importers, ...) can each decide
+// how they want to react to it.
+type Category int
+
+const (
+	// CategoryFatal means the error should not be ignored or retried.
+	CategoryFatal Category = iota
+	// CategoryDDLIdempotent means the error comes from replaying a DDL
+	// statement that has already been applied, e.g. "table already exists".
+	CategoryDDLIdempotent
+	// CategoryDuplicate means the error is a duplicate-entry style error,
+	// e.g. a duplicate key on INSERT.
+	CategoryDuplicate
+	// CategoryRetryable means the error is transient, e.g. a lock-wait
+	// timeout or deadlock, and the statement can be retried as-is.
+	CategoryRetryable
+)
+
+// ErrorPolicy decides, for a given error, which Category it falls into.
+// The zero value is not usable; construct one with NewDefaultErrorPolicy or
+// NewErrorPolicy.
+type ErrorPolicy struct {
+	categories map[uint16]Category
+}
+
+// defaultErrorCodes reproduces the behaviour ignoreError used to implement:
+// only "object exists/doesn't exist" errors raised by idempotent DDL replay
+// are ignorable. It also classifies duplicate-entry and transient errors;
+// every code it doesn't list is fatal.
+var defaultErrorCodes = map[uint16]Category{
+	uint16(infoschema.ErrDatabaseExists.Code()):     CategoryDDLIdempotent,
+	uint16(infoschema.ErrDatabaseDropExists.Code()): CategoryDDLIdempotent,
+	uint16(infoschema.ErrTableExists.Code()):        CategoryDDLIdempotent,
+	uint16(infoschema.ErrTableDropExists.Code()):    CategoryDDLIdempotent,
+	uint16(infoschema.ErrColumnExists.Code()):       CategoryDDLIdempotent,
+	uint16(infoschema.ErrIndexExists.Code()):        CategoryDDLIdempotent,
+
+	// ER_TABLE_EXISTS_ERROR, ER_BAD_TABLE_ERROR, ER_BAD_FIELD_ERROR,
+	// ER_DUP_KEYNAME. 1050, 1051 and 1061 are the same codes as
+	// infoschema's ErrTableExists, ErrTableDropExists and ErrIndexExists
+	// above; 1054 (unknown column) is classified as idempotent DDL as well.
+	1050: CategoryDDLIdempotent,
+	1051: CategoryDDLIdempotent,
+	1054: CategoryDDLIdempotent,
+	1061: CategoryDDLIdempotent,
+
+	// ER_DUP_ENTRY, ER_DUP_KEY, ER_DUP_ENTRY_WITH_KEY_NAME
+	1062: CategoryDuplicate,
+	1022: CategoryDuplicate,
+	1586: CategoryDuplicate,
+
+	// ER_LOCK_WAIT_TIMEOUT, ER_LOCK_DEADLOCK
+	1205: CategoryRetryable,
+	1213: CategoryRetryable,
+	// ER_TOO_MANY_CONCURRENT_TRXS
+	1637: CategoryRetryable,
+}
+
+// NewDefaultErrorPolicy returns the ErrorPolicy that reproduces today's
+// default behaviour: only idempotent-DDL "already exists/doesn't exist"
+// errors are ignorable.
+func NewDefaultErrorPolicy() *ErrorPolicy {
+	categories := make(map[uint16]Category, len(defaultErrorCodes))
+	for code, category := range defaultErrorCodes {
+		categories[code] = category
+	}
+	return &ErrorPolicy{categories: categories}
+}
+
+// NewErrorPolicy builds an ErrorPolicy from an explicit code -> category
+// mapping. Codes absent from categories are classified as CategoryFatal.
+func NewErrorPolicy(categories map[uint16]Category) *ErrorPolicy {
+	return &ErrorPolicy{categories: categories}
+}
+
+// Classify returns the Category err belongs to under this policy. Errors
+// that aren't a *mysql.MySQLError are always CategoryFatal.
+func (p *ErrorPolicy) Classify(err error) Category {
+	if err == nil {
+		return CategoryFatal
+	}
+
+	mysqlErr, ok := errors.Cause(err).(*gmysql.MySQLError)
+	if !ok {
+		return CategoryFatal
+	}
+
+	if category, ok := p.categories[mysqlErr.Number]; ok {
+		return category
+	}
+	return CategoryFatal
+}
+
+// ShouldIgnore reports whether err can be dropped outright under this
+// policy, e.g. while replaying idempotent DDL. Duplicate-entry errors are
+// never ignored here: they indicate a real unique-constraint violation, not
+// a replay of something already applied, and must still surface to the
+// caller.
+func (p *ErrorPolicy) ShouldIgnore(err error) bool {
+	return p.Classify(err) == CategoryDDLIdempotent
+}
+
+// ShouldRetry reports whether err is worth retrying given this is the
+// attempt'th attempt (1-based) at the statement that produced it, and if so
+// how long to back off before retrying.
+func (p *ErrorPolicy) ShouldRetry(err error, attempt int) (bool, time.Duration) {
+	if p.Classify(err) != CategoryRetryable {
+		return false, 0
+	}
+
+	backoff := time.Duration(attempt) * 100 * time.Millisecond
+	if backoff > 2*time.Second {
+		backoff = 2 * time.Second
+	}
+	return true, backoff
+}
+
+// defaultErrorPolicy is the ErrorPolicy used by functions in this package
+// that don't have a WithPolicy option applied.
+var defaultErrorPolicy = NewDefaultErrorPolicy()
+
+// Classify classifies err against the package's default ErrorPolicy.
+func Classify(err error) Category {
+	return defaultErrorPolicy.Classify(err)
+}
+
+// ShouldRetry reports whether err is retryable under the package's default
+// ErrorPolicy, and the backoff to wait before retrying attempt.
+func ShouldRetry(err error, attempt int) (bool, time.Duration) {
+	return defaultErrorPolicy.ShouldRetry(err, attempt)
+}
+
+// ignoreError returns true if err can be ignored under the package's
+// default ErrorPolicy, e.g. "table already exists" while replaying a CREATE
+// TABLE statement.
+func ignoreError(err error) bool {
+	return defaultErrorPolicy.ShouldIgnore(err)
+}
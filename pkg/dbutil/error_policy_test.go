@@ -0,0 +1,71 @@
+// Copyright 2018 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbutil
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+)
+
+func (s *testDBSuite) TestErrorPolicyClassify(c *C) {
+	cases := []struct {
+		number   uint16
+		category Category
+	}{
+		{1050, CategoryDDLIdempotent}, // ER_TABLE_EXISTS_ERROR
+		{1051, CategoryDDLIdempotent}, // ER_BAD_TABLE_ERROR
+		{1054, CategoryDDLIdempotent}, // ER_BAD_FIELD_ERROR
+		{1061, CategoryDDLIdempotent}, // ER_DUP_KEYNAME
+		{1205, CategoryRetryable},     // ER_LOCK_WAIT_TIMEOUT
+		{1213, CategoryRetryable},     // ER_LOCK_DEADLOCK
+		{1290, CategoryFatal},         // ER_OPTION_PREVENTS_STATEMENT
+	}
+
+	policy := NewDefaultErrorPolicy()
+	for _, ca := range cases {
+		c.Assert(policy.Classify(newMysqlErr(ca.number, "test error")), Equals, ca.category)
+	}
+
+	c.Assert(Classify(newMysqlErr(1205, "test error")), Equals, CategoryRetryable)
+	c.Assert(Classify(errors.New("not a mysql error")), Equals, CategoryFatal)
+}
+
+func (s *testDBSuite) TestErrorPolicyShouldRetry(c *C) {
+	retry, backoff := ShouldRetry(newMysqlErr(1205, "lock wait timeout"), 1)
+	c.Assert(retry, Equals, true)
+	c.Assert(backoff > 0, Equals, true)
+
+	retry, _ = ShouldRetry(newMysqlErr(1290, "read-only"), 1)
+	c.Assert(retry, Equals, false)
+}
+
+func (s *testDBSuite) TestErrorPolicyShouldIgnore(c *C) {
+	policy := NewDefaultErrorPolicy()
+
+	c.Assert(policy.ShouldIgnore(newMysqlErr(1050, "table exists")), Equals, true)
+	// duplicate-entry errors are a real unique-constraint violation, not a
+	// replay of something already applied, and must never be ignored.
+	c.Assert(policy.ShouldIgnore(newMysqlErr(1062, "duplicate entry")), Equals, false)
+}
+
+func (s *testDBSuite) TestErrorPolicyCustom(c *C) {
+	policy := NewErrorPolicy(map[uint16]Category{
+		1290: CategoryRetryable,
+	})
+
+	c.Assert(policy.Classify(newMysqlErr(1290, "read-only")), Equals, CategoryRetryable)
+	// codes absent from a custom policy fall back to fatal, not the
+	// package default's classification.
+	c.Assert(policy.Classify(newMysqlErr(1050, "table exists")), Equals, CategoryFatal)
+}
@@ -0,0 +1,74 @@
+// Copyright 2018 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbutil
+
+import "strings"
+
+// Quoter quotes an identifier (a schema, table or column name) for a
+// particular SQL dialect, doubling any embedded occurrence of the quote
+// character it uses.
+type Quoter interface {
+	Quote(name string) string
+}
+
+type quoteCharQuoter struct {
+	open, close byte
+}
+
+func (q quoteCharQuoter) Quote(name string) string {
+	// Only the closing quote character needs escaping: MySQL's backtick and
+	// ANSI's double-quote use the same rune for both, but bracket quoting
+	// escapes only embedded ']', not '['.
+	escaped := strings.Replace(name, string(q.close), string(q.close)+string(q.close), -1)
+	return string(q.open) + escaped + string(q.close)
+}
+
+var (
+	// MySQLQuoter quotes identifiers MySQL/TiDB style, e.g. `name`. This is
+	// the default dialect used by TableName/ColumnName.
+	MySQLQuoter Quoter = quoteCharQuoter{open: '`', close: '`'}
+	// ANSIQuoter quotes identifiers ANSI SQL style, e.g. "name", as used by
+	// MySQL/TiDB in ANSI_QUOTES mode, PostgreSQL and Oracle.
+	ANSIQuoter Quoter = quoteCharQuoter{open: '"', close: '"'}
+	// BracketQuoter quotes identifiers SQL Server style, e.g. [name].
+	BracketQuoter Quoter = quoteCharQuoter{open: '[', close: ']'}
+)
+
+// QuoterForSQLMode returns the Quoter matching the quoting rules implied by
+// sqlModeStr (a comma separated SQL mode list, as accepted by getParser):
+// ANSIQuoter when ANSI_QUOTES is set, MySQLQuoter otherwise.
+func QuoterForSQLMode(sqlModeStr string) (Quoter, error) {
+	sqlMode, err := parseSQLMode(sqlModeStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if sqlMode.HasANSIQuotesMode() {
+		return ANSIQuoter, nil
+	}
+	return MySQLQuoter, nil
+}
+
+// TableNameWithQuoter returns the precise full name of a table, quoted with
+// quoter, e.g. `TableNameWithQuoter(ANSIQuoter, "test", "t1")` returns
+// `"test"."t1"`.
+func TableNameWithQuoter(quoter Quoter, schema, table string) string {
+	return quoter.Quote(schema) + "." + quoter.Quote(table)
+}
+
+// ColumnNameWithQuoter returns the precise full name of a column, quoted
+// with quoter.
+func ColumnNameWithQuoter(quoter Quoter, name string) string {
+	return quoter.Quote(name)
+}
@@ -0,0 +1,66 @@
+// Copyright 2018 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbutil
+
+import (
+	. "github.com/pingcap/check"
+)
+
+func (s *testDBSuite) TestQuoterQuote(c *C) {
+	cases := []struct {
+		quoter Quoter
+		name   string
+		expect string
+	}{
+		{MySQLQuoter, "testa", "`testa`"},
+		{MySQLQuoter, "t`esta", "`t``esta`"},
+		{ANSIQuoter, "testa", `"testa"`},
+		{ANSIQuoter, `t"esta`, `"t""esta"`},
+		{BracketQuoter, "testa", "[testa]"},
+		{BracketQuoter, "t]esta", "[t]]esta]"},
+	}
+
+	for _, ca := range cases {
+		c.Assert(ca.quoter.Quote(ca.name), Equals, ca.expect)
+	}
+}
+
+func (s *testDBSuite) TestTableNameColumnNameWithQuoter(c *C) {
+	c.Assert(TableNameWithQuoter(ANSIQuoter, "test", "t1"), Equals, `"test"."t1"`)
+	c.Assert(ColumnNameWithQuoter(BracketQuoter, "id"), Equals, "[id]")
+}
+
+func (s *testDBSuite) TestQuoterForSQLMode(c *C) {
+	cases := []struct {
+		sqlModeStr string
+		expect     Quoter
+		hasErr     bool
+	}{
+		{"", MySQLQuoter, false},
+		{"ANSI_QUOTES", ANSIQuoter, false},
+		{"ANSI_QUOTES,IGNORE_SPACE", ANSIQuoter, false},
+		{"IGNORE_SPACE", MySQLQuoter, false},
+		{"ANSI_QUOTES123", nil, true},
+	}
+
+	for _, ca := range cases {
+		quoter, err := QuoterForSQLMode(ca.sqlModeStr)
+		if ca.hasErr {
+			c.Assert(err, NotNil)
+			continue
+		}
+		c.Assert(err, IsNil)
+		c.Assert(quoter, Equals, ca.expect)
+	}
+}